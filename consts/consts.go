@@ -1,10 +1,51 @@
 package consts
 
 const (
-	BNODE_BNODE        = 1
-	BNODE_BLEAF        = 2
-	HEADER             = 4
+	BNODE_BNODE = 1
+	BNODE_BLEAF = 2
+	// HEADER is [btype(2) nkeys(2)][checksum(4)][lsn(8)]: the type/nkeys
+	// pair every node always had, plus a crc32 guarding the rest of the
+	// page's bytes and a monotonically increasing LSN stamped by
+	// BTree.alloc, following bcachefs's bset header (see
+	// bnode.BNode.SetChecksum/VerifyChecksum). Together they let Get
+	// notice a torn write before the corrupted bytes reach the rest of
+	// the tree.
+	HEADER             = 4 + 4 + 8
 	BTREE_PAGE_SIZE    = 4096
 	BTREE_MAX_KEY_SIZE = 1000
 	BTREE_MAX_VAL_SIZE = 3000
 )
+
+// on-disk layout for the pager: a fixed header page (page 0) and a
+// free list of reclaimed pages threaded through ordinary pages.
+const (
+	// DB_SIG is written at the start of the header page so Open can
+	// tell a freshly created file from garbage.
+	DB_SIG = "toastsandwich-db-v1"
+
+	// BNODE_FREE_LIST marks a page as a free-list node rather than a
+	// BTree node, so the two page types never get confused on load.
+	BNODE_FREE_LIST = 3
+
+	// free-list node layout: [type(2) size(2)][next uint64][ptr * size]
+	FREE_LIST_HEADER = 4 + 8
+	FREE_LIST_CAP    = (BTREE_PAGE_SIZE - FREE_LIST_HEADER) / 8
+)
+
+// overflow values: a KV pair whose value is bigger than what can live
+// inline on a page (see bnode.PutLargeVal/GetLargeVal).
+const (
+	// VLEN_OVERFLOW_FLAG is OR'd into a leaf KV's on-page vlen to mark
+	// that the stored bytes aren't the value itself but a flat array
+	// of 8-byte pointers to the overflow pages holding it.
+	VLEN_OVERFLOW_FLAG = 1 << 15
+
+	// BTREE_VAL_OVERFLOW_THRESHOLD is the largest value NodeAppendKV
+	// still stores inline; anything bigger is routed through the
+	// overflow path instead. It defaults to BTREE_MAX_VAL_SIZE but is
+	// its own constant so the overflow path can be tuned independently
+	// of the per-page size guarantee BTree.go's nodelmax check relies
+	// on - the overflow pointer array itself must still fit inline
+	// within that same BTREE_MAX_VAL_SIZE budget.
+	BTREE_VAL_OVERFLOW_THRESHOLD = BTREE_MAX_VAL_SIZE
+)
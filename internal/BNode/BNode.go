@@ -2,6 +2,7 @@ package bnode
 
 import (
 	"encoding/binary"
+	"hash/crc32"
 
 	"github.com/toastsandwich/create-database/consts"
 	"github.com/toastsandwich/create-database/utils"
@@ -55,7 +56,50 @@ func (n *BNode) NKeys() uint16 {
 // This method sets the header of the B-tree node, including its type and the number of keys.
 func (n *BNode) SetHeader(btype, nkeys uint16) {
 	binary.LittleEndian.PutUint16(n.Data[0:2], btype)
-	binary.LittleEndian.PutUint16(n.Data, nkeys)
+	binary.LittleEndian.PutUint16(n.Data[2:4], nkeys)
+}
+
+// checksum and LSN (see consts.HEADER for the full header layout)
+
+// Checksum returns the node's stored crc32, with no recomputation -
+// see VerifyChecksum to check it against the node's actual bytes.
+func (n *BNode) Checksum() uint32 {
+	return binary.LittleEndian.Uint32(n.Data[4:8])
+}
+
+// LSN returns the node's stamped page LSN, set by BTree.alloc the last
+// time this page was written.
+func (n *BNode) LSN() uint64 {
+	return binary.LittleEndian.Uint64(n.Data[8:16])
+}
+
+// SetLSN stamps the node's page LSN. Call it before SetChecksum, since
+// the checksum covers the LSN field.
+func (n *BNode) SetLSN(lsn uint64) {
+	binary.LittleEndian.PutUint64(n.Data[8:16], lsn)
+}
+
+// computeChecksum covers everything in the node except the checksum
+// field itself: the btype/nkeys header, the LSN, and every pointer,
+// offset and KV byte the node actually uses.
+func (n *BNode) computeChecksum() uint32 {
+	h := crc32.NewIEEE()
+	h.Write(n.Data[0:4])
+	h.Write(n.Data[8:n.Nbyte()])
+	return h.Sum32()
+}
+
+// SetChecksum stamps the node's checksum field. Call it last, once the
+// node's content and LSN are both final.
+func (n *BNode) SetChecksum() {
+	binary.LittleEndian.PutUint32(n.Data[4:8], n.computeChecksum())
+}
+
+// VerifyChecksum reports whether the node's stored checksum matches
+// its current bytes - false means the page was torn or corrupted in
+// whatever wrote or read it.
+func (n *BNode) VerifyChecksum() bool {
+	return n.Checksum() == n.computeChecksum()
 }
 
 // pointer
@@ -121,15 +165,26 @@ func (n *BNode) GetKey(idx uint16) []byte {
 	return n.Data[pos+4:][:klen]
 }
 
-// Retrieves the value associated with a specific index within the node.
+// Retrieves the value associated with a specific index within the
+// node. If IsOverflowVal(idx) is true, this is not the real value but
+// the on-page pointer array bnode.GetLargeVal needs to reassemble it.
 func (n *BNode) GetVal(idx uint16) []byte {
 	utils.Assert(idx <= n.NKeys())
 	pos := n.KVPos(idx)
 	klen := binary.LittleEndian.Uint16(n.Data[pos+0:])
-	vlen := binary.LittleEndian.Uint16(n.Data[pos+2:])
+	vlen := binary.LittleEndian.Uint16(n.Data[pos+2:]) &^ consts.VLEN_OVERFLOW_FLAG
 	return n.Data[pos+4+klen:][:vlen]
 }
 
+// IsOverflowVal reports whether idx's value is stored out-of-line, per
+// the flag NodeAppendKV sets in vlen.
+func (n *BNode) IsOverflowVal(idx uint16) bool {
+	utils.Assert(idx <= n.NKeys())
+	pos := n.KVPos(idx)
+	vlen := binary.LittleEndian.Uint16(n.Data[pos+2:])
+	return vlen&consts.VLEN_OVERFLOW_FLAG != 0
+}
+
 // determinze the size of the node
 func (n *BNode) Nbyte() uint16 {
 	return n.KVPos(n.NKeys())
@@ -140,17 +195,40 @@ func (n *BNode) Nbyte() uint16 {
 
 //-------------------------------
 
-// add a new key to a leaf node
+// add a new key to a leaf node. val is the on-page bytes to store -
+// callers routing through the overflow path pass the pointer array
+// bnode.PutLargeVal returns, with overflow set to true, rather than
+// the real value.
 func LeafInsert(
 	new BNode, old BNode, idx uint16,
-	key []byte, val []byte,
+	key []byte, val []byte, overflow bool,
 ) {
 	new.SetHeader(consts.BNODE_BLEAF, old.NKeys()+1)
 	NodeAppendRange(new, old, 0, 0, idx)
-	NodeAppendKV(new, idx, 0, key, val)
+	NodeAppendKV(new, idx, 0, key, val, overflow)
 	NodeAppendRange(new, old, idx+1, idx, old.NKeys()-idx)
 }
 
+// update the value for an existing key in place (same idx in the new
+// node, unlike LeafInsert which shifts everything from idx onward).
+// See LeafInsert for what val/overflow mean.
+func LeafUpdate(
+	new BNode, old BNode, idx uint16,
+	key []byte, val []byte, overflow bool,
+) {
+	new.SetHeader(consts.BNODE_BLEAF, old.NKeys())
+	NodeAppendRange(new, old, 0, 0, idx)
+	NodeAppendKV(new, idx, 0, key, val, overflow)
+	NodeAppendRange(new, old, idx+1, idx+1, old.NKeys()-idx-1)
+}
+
+// remove a key from a leaf node
+func LeafDelete(new BNode, old BNode, idx uint16) {
+	new.SetHeader(consts.BNODE_BLEAF, old.NKeys()-1)
+	NodeAppendRange(new, old, 0, 0, idx)
+	NodeAppendRange(new, old, idx, idx+1, old.NKeys()-idx-1)
+}
+
 //nodeAppendRange function copies keys from an old node to a new node
 
 // copy multiple KVs into the poition
@@ -182,13 +260,20 @@ func NodeAppendRange(
 	copy(new.Data[new.KVPos(dstNew):], old.Data[begin:end])
 }
 
-func NodeAppendKV(new BNode, idx uint16, ptr uint64, key []byte, val []byte) {
+// overflow marks val as an overflow pointer array rather than a real
+// value, by setting VLEN_OVERFLOW_FLAG in the stored vlen - see
+// GetVal/IsOverflowVal for the read side.
+func NodeAppendKV(new BNode, idx uint16, ptr uint64, key []byte, val []byte, overflow bool) {
 	// ptrs
 	new.SetPtr(idx, ptr)
 	// KVs
 	pos := new.KVPos(idx)
+	vlen := uint16(len(val))
+	if overflow {
+		vlen |= consts.VLEN_OVERFLOW_FLAG
+	}
 	binary.LittleEndian.PutUint16(new.Data[pos+0:], uint16(len(key)))
-	binary.LittleEndian.PutUint16(new.Data[pos+2:], uint16(len(val)))
+	binary.LittleEndian.PutUint16(new.Data[pos+2:], vlen)
 	copy(new.Data[pos+4:], key)
 	copy(new.Data[pos+4+uint16(len(key)):], val)
 	// the offset of next key
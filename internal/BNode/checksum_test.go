@@ -0,0 +1,58 @@
+package bnode
+
+import (
+	"testing"
+
+	"github.com/toastsandwich/create-database/consts"
+)
+
+// buildChecksumTestNode packs a handful of sorted KV pairs into a leaf
+// node, stamps a page LSN, and computes its checksum - the same
+// sequence BTree.alloc runs before handing a node to New.
+func buildChecksumTestNode() BNode {
+	node := BNode{Data: make([]byte, consts.BTREE_PAGE_SIZE)}
+	node.SetHeader(consts.BNODE_BLEAF, 3)
+	for i := 0; i < 3; i++ {
+		key := []byte{byte('a' + i)}
+		val := []byte{byte('A' + i)}
+		NodeAppendKV(node, uint16(i), 0, key, val, false)
+	}
+	node.SetLSN(7)
+	node.SetChecksum()
+	return node
+}
+
+func TestVerifyChecksumAcceptsAnUntamperedNode(t *testing.T) {
+	node := buildChecksumTestNode()
+	if !node.VerifyChecksum() {
+		t.Fatal("VerifyChecksum should accept a node right after SetChecksum")
+	}
+	if node.LSN() != 7 {
+		t.Fatalf("LSN = %d, want 7", node.LSN())
+	}
+}
+
+func TestVerifyChecksumCatchesContentCorruption(t *testing.T) {
+	node := buildChecksumTestNode()
+	node.Data[consts.HEADER+2] ^= 0xFF // flip a byte inside the pointer/KV area
+	if node.VerifyChecksum() {
+		t.Fatal("VerifyChecksum should reject a node whose content changed after SetChecksum")
+	}
+}
+
+func TestVerifyChecksumCatchesLSNCorruption(t *testing.T) {
+	node := buildChecksumTestNode()
+	node.SetLSN(node.LSN() + 1) // corrupt the LSN without redoing the checksum
+	if node.VerifyChecksum() {
+		t.Fatal("VerifyChecksum should reject a node whose LSN changed after SetChecksum")
+	}
+}
+
+func TestSetChecksumDoesNotCoverItsOwnField(t *testing.T) {
+	node := buildChecksumTestNode()
+	before := node.Checksum()
+	node.SetChecksum() // recomputing over the same content must be stable
+	if node.Checksum() != before {
+		t.Fatal("SetChecksum should be idempotent over unchanged content")
+	}
+}
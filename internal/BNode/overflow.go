@@ -0,0 +1,98 @@
+package bnode
+
+import (
+	"encoding/binary"
+
+	"github.com/toastsandwich/create-database/consts"
+)
+
+/*
+A normal KV entry's value lives inline in the page it's on, which is
+why BTREE_MAX_VAL_SIZE exists - a leaf has to fit at least one KV pair
+alone. PutLargeVal/GetLargeVal let a value bigger than
+BTREE_VAL_OVERFLOW_THRESHOLD live on its own chain of pages instead:
+what ends up inline is a flat array of 8-byte pointers to those pages
+(stored via NodeAppendKV with overflow=true, see VLEN_OVERFLOW_FLAG),
+and the first page in the chain also carries the value's real byte
+length so the chain can be reassembled exactly, including its last,
+partially-filled page.
+*/
+
+// overflowHeadCap and overflowPageCap are how many value bytes fit on
+// the first overflow page (which also carries the total length ahead
+// of its data) and on every page after it.
+const (
+	overflowHeadCap = consts.BTREE_PAGE_SIZE - 8
+	overflowPageCap = consts.BTREE_PAGE_SIZE
+)
+
+// OverflowPageCount returns how many overflow pages an n-byte value
+// needs. Callers use it up front to reject values whose pointer array
+// wouldn't fit inline even after overflowing (see
+// BTREE_VAL_OVERFLOW_THRESHOLD).
+func OverflowPageCount(n int) int {
+	if n <= overflowHeadCap {
+		return 1
+	}
+	n -= overflowHeadCap
+	return 1 + (n+overflowPageCap-1)/overflowPageCap
+}
+
+// PutLargeVal chains val across as many pages as it takes, allocating
+// each one via new, and returns the on-page pointer array the caller
+// should store in place of val (via NodeAppendKV/LeafInsert/LeafUpdate
+// with overflow=true).
+func PutLargeVal(new func(BNode) uint64, val []byte) []byte {
+	n := OverflowPageCount(len(val))
+	ptrs := make([]byte, n*8)
+
+	head := BNode{Data: make([]byte, consts.BTREE_PAGE_SIZE)}
+	binary.LittleEndian.PutUint64(head.Data[0:8], uint64(len(val)))
+	written := copy(head.Data[8:], val)
+	binary.LittleEndian.PutUint64(ptrs[0:8], new(head))
+
+	rest := val[written:]
+	for i := 1; i < n; i++ {
+		page := BNode{Data: make([]byte, consts.BTREE_PAGE_SIZE)}
+		k := copy(page.Data, rest)
+		rest = rest[k:]
+		binary.LittleEndian.PutUint64(ptrs[i*8:], new(page))
+	}
+	return ptrs
+}
+
+// GetLargeVal walks the pointer array ptrs (as PutLargeVal built it,
+// and NodeAppendKV stored inline) and reassembles the original value,
+// dereferencing each overflow page with get.
+func GetLargeVal(get func(uint64) BNode, ptrs []byte) []byte {
+	n := len(ptrs) / 8
+	head := get(binary.LittleEndian.Uint64(ptrs[0:8]))
+	total := int(binary.LittleEndian.Uint64(head.Data[0:8]))
+
+	val := make([]byte, 0, total)
+	headN := overflowHeadCap
+	if headN > total {
+		headN = total
+	}
+	val = append(val, head.Data[8:8+headN]...)
+
+	for i := 1; i < n; i++ {
+		page := get(binary.LittleEndian.Uint64(ptrs[i*8:]))
+		take := overflowPageCap
+		if remain := total - len(val); take > remain {
+			take = remain
+		}
+		val = append(val, page.Data[:take]...)
+	}
+	return val
+}
+
+// FreeLargeVal returns every page in ptrs to the free list via del. It
+// must run before the leaf entry pointing at ptrs is itself discarded,
+// the same way a deleted internal node's children are freed before the
+// node that pointed at them.
+func FreeLargeVal(del func(uint64), ptrs []byte) {
+	for i := 0; i < len(ptrs); i += 8 {
+		del(binary.LittleEndian.Uint64(ptrs[i:]))
+	}
+}
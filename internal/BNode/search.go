@@ -0,0 +1,151 @@
+package bnode
+
+import (
+	"bytes"
+)
+
+/*
+The offset list (see OffsetPos/GetOffset) already stores keys in
+sorted order, which earlier only mattered for NodeAppendRange - nothing
+actually looked a key up yet, so any caller had to scan linearly.
+FindKey and EytzingerIndex below are the two ways to do better: plain
+binary search over the existing sorted layout, and an optional,
+lazily-built breadth-first ("Eytzinger") copy of the keys for nodes
+that get searched repeatedly.
+*/
+
+// linearFindKey is the naive baseline FindKey replaces: scan every key
+// in order and remember the last one <= key. It is unexported and
+// exists only so the benchmarks in search_bench_test.go have something
+// to compare the binary-search and Eytzinger paths against.
+func linearFindKey(n *BNode, key []byte) (idx uint16, found bool) {
+	nkeys := n.NKeys()
+	var last uint16
+	var have bool
+	for i := uint16(0); i < nkeys; i++ {
+		cmp := bytes.Compare(n.GetKey(i), key)
+		if cmp == 0 {
+			return i, true
+		}
+		if cmp < 0 {
+			last, have = i, true
+		} else {
+			break
+		}
+	}
+	if !have {
+		return 0, false
+	}
+	return last, false
+}
+
+// FindKey returns the index of the last key <= key - the position to
+// descend into for an internal node, or to update/insert at for a leaf
+// - using binary search over the sorted offset list instead of a
+// linear scan. found reports whether key matched exactly.
+func (n *BNode) FindKey(key []byte) (idx uint16, found bool) {
+	nkeys := n.NKeys()
+	lo, hi := uint16(0), nkeys // search [lo, hi)
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		cmp := bytes.Compare(n.GetKey(mid), key)
+		switch {
+		case cmp == 0:
+			return mid, true
+		case cmp < 0:
+			lo = mid + 1
+		default:
+			hi = mid
+		}
+	}
+	if lo == 0 {
+		return 0, false
+	}
+	return lo - 1, false
+}
+
+// EytzingerIndex lays a node's keys out in level order (breadth-first)
+// instead of sorted order - the layout bcachefs's bset.c builds for
+// its in-memory search trees. Walking it with i = 2*i / 2*i+1 visits
+// the array in the order a search actually takes, so the working set
+// stays cache-line friendly and, unlike a sorted array, the branch
+// direction doesn't depend on where in the key range the lookup key
+// falls. It costs O(n) to build, so it is worth it only for internal
+// nodes that get searched many times while resident.
+type EytzingerIndex struct {
+	keys []([]byte) // 1-indexed; nil past the real keys (+inf sentinel)
+	rank []uint16   // rank[i] is FindKey's index for keys[i]
+	size int        // next power of two >= NKeys(), array capacity
+	n    uint16     // NKeys() at build time
+}
+
+// BuildEytzingerIndex builds the level-order layout for every key
+// currently on the page. The result is a point-in-time snapshot: it
+// must be rebuilt if the page's bytes change underneath it.
+func BuildEytzingerIndex(n *BNode) *EytzingerIndex {
+	nkeys := n.NKeys()
+	size := 1
+	for size < int(nkeys) {
+		size *= 2
+	}
+	e := &EytzingerIndex{
+		keys: make([][]byte, size+1),
+		rank: make([]uint16, size+1),
+		size: size,
+		n:    nkeys,
+	}
+	for i := range e.rank {
+		e.rank[i] = nkeys // sentinel: "no key here", past the last real key
+	}
+
+	var fill func(pos int, lo, hi uint16)
+	fill = func(pos int, lo, hi uint16) {
+		if lo >= hi || pos > size {
+			return
+		}
+		mid := lo + (hi-lo)/2
+		e.keys[pos] = n.GetKey(mid)
+		e.rank[pos] = mid
+		fill(2*pos, lo, mid)
+		fill(2*pos+1, mid+1, hi)
+	}
+	fill(1, 0, nkeys)
+	return e
+}
+
+// Find searches for key and, like BNode.FindKey, returns the index of
+// the last key <= key, but walks the Eytzinger layout instead of the
+// sorted offset list. It tracks the deepest key found to be <= key as
+// it descends (the floor candidate refines every time the walk goes
+// right), rather than trying to recover that position from the
+// terminal i after the loop - unlike the standard Eytzinger
+// lower-bound recipe, a descent here can pass through nil "+inf"
+// padding slots, and reconstructing the answer from i's bit pattern
+// alone doesn't account for those.
+func (e *EytzingerIndex) Find(key []byte) (idx uint16, found bool) {
+	i := 1
+	var floorRank uint16
+	haveFloor := false
+	for i <= e.size {
+		k := e.keys[i]
+		if k == nil {
+			// past the real keys: treat as +infinity
+			i = 2 * i
+			continue
+		}
+		cmp := bytes.Compare(k, key)
+		switch {
+		case cmp == 0:
+			return e.rank[i], true
+		case cmp < 0:
+			floorRank, haveFloor = e.rank[i], true
+			i = 2*i + 1
+		default:
+			i = 2 * i
+		}
+	}
+	if !haveFloor {
+		return 0, false // key is smaller than every key on the page
+	}
+	return floorRank, false
+}
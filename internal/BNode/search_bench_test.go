@@ -0,0 +1,77 @@
+package bnode
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/toastsandwich/create-database/consts"
+)
+
+// buildBenchNode packs n sorted, fixed-width KV pairs into a single
+// page-sized leaf node, the same way LeafInsert would build one up one
+// key at a time.
+func buildBenchNode(n int) BNode {
+	node := BNode{Data: make([]byte, consts.BTREE_PAGE_SIZE)}
+	node.SetHeader(consts.BNODE_BLEAF, uint16(n))
+	for i := 0; i < n; i++ {
+		key := []byte(fmt.Sprintf("key-%08d", i))
+		val := []byte(fmt.Sprintf("val-%08d", i))
+		NodeAppendKV(node, uint16(i), 0, key, val, false)
+	}
+	return node
+}
+
+// benchSizes mirrors how many keys a ~4K page realistically holds for
+// this fixture's fixed-width keys/values (see buildBenchNode).
+var benchSizes = []int{8, 32, 100}
+
+func BenchmarkFindKeyLinear(b *testing.B) {
+	for _, n := range benchSizes {
+		node := buildBenchNode(n)
+		target := []byte(fmt.Sprintf("key-%08d", n*3/4))
+		b.Run(fmt.Sprintf("n=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				linearFindKey(&node, target)
+			}
+		})
+	}
+}
+
+func BenchmarkFindKeySortedBinary(b *testing.B) {
+	for _, n := range benchSizes {
+		node := buildBenchNode(n)
+		target := []byte(fmt.Sprintf("key-%08d", n*3/4))
+		b.Run(fmt.Sprintf("n=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				node.FindKey(target)
+			}
+		})
+	}
+}
+
+func BenchmarkFindKeyEytzinger(b *testing.B) {
+	for _, n := range benchSizes {
+		node := buildBenchNode(n)
+		target := []byte(fmt.Sprintf("key-%08d", n*3/4))
+		b.Run(fmt.Sprintf("n=%d", n), func(b *testing.B) {
+			idx := BuildEytzingerIndex(&node)
+			for i := 0; i < b.N; i++ {
+				idx.Find(target)
+			}
+		})
+	}
+}
+
+// BenchmarkBuildEytzingerIndex isolates the build cost the other
+// benchmarks hoist out of their loop, since the index only pays off
+// when a page is searched more than once while resident.
+func BenchmarkBuildEytzingerIndex(b *testing.B) {
+	for _, n := range benchSizes {
+		node := buildBenchNode(n)
+		b.Run(fmt.Sprintf("n=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				BuildEytzingerIndex(&node)
+			}
+		})
+	}
+}
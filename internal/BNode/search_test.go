@@ -0,0 +1,81 @@
+package bnode
+
+import (
+	"fmt"
+	"testing"
+)
+
+// searchTestSizes deliberately includes non-power-of-two counts, since
+// BuildEytzingerIndex pads up to the next power of two and that's
+// exactly where an off-by-one in the padding/recovery logic would show
+// up.
+var searchTestSizes = []int{0, 1, 2, 3, 4, 5, 7, 8, 9, 15, 16, 17, 31, 32, 33, 100}
+
+// searchTestQueries returns every interesting query against an
+// n-key node built by buildBenchNode: below every key, above every
+// key, an exact match on each key, and a key strictly between each
+// adjacent pair.
+func searchTestQueries(n int) [][]byte {
+	queries := [][]byte{
+		[]byte("aaa"), // below every key (buildBenchNode's keys all start with "key-")
+		[]byte("zzz"), // above every key
+	}
+	for i := 0; i < n; i++ {
+		queries = append(queries, []byte(fmt.Sprintf("key-%08d", i)))
+	}
+	for i := 0; i < n-1; i++ {
+		// lies strictly between key i and key i+1: a string that has
+		// key(i) as a strict prefix sorts right after it and before
+		// key(i+1), which shares none of that extra byte.
+		queries = append(queries, []byte(fmt.Sprintf("key-%08dx", i)))
+	}
+	return queries
+}
+
+// TestFindKeyMatchesLinearScan checks BNode.FindKey against the
+// unoptimized linear-scan baseline across node sizes and the full
+// query range.
+func TestFindKeyMatchesLinearScan(t *testing.T) {
+	for _, n := range searchTestSizes {
+		node := buildBenchNode(n)
+		for _, q := range searchTestQueries(n) {
+			wantIdx, wantFound := linearFindKey(&node, q)
+			gotIdx, gotFound := node.FindKey(q)
+			if gotIdx != wantIdx || gotFound != wantFound {
+				t.Fatalf("n=%d FindKey(%q) = (%d, %v), want (%d, %v)",
+					n, q, gotIdx, gotFound, wantIdx, wantFound)
+			}
+		}
+	}
+}
+
+// TestEytzingerFindMatchesLinearScan checks EytzingerIndex.Find against
+// the same oracle and query range as TestFindKeyMatchesLinearScan -
+// this is the regression test for the bug where Find returned the
+// last-key sentinel instead of (0, false) for a below-all-keys query.
+func TestEytzingerFindMatchesLinearScan(t *testing.T) {
+	for _, n := range searchTestSizes {
+		node := buildBenchNode(n)
+		idx := BuildEytzingerIndex(&node)
+		for _, q := range searchTestQueries(n) {
+			wantIdx, wantFound := linearFindKey(&node, q)
+			gotIdx, gotFound := idx.Find(q)
+			if gotIdx != wantIdx || gotFound != wantFound {
+				t.Fatalf("n=%d EytzingerIndex.Find(%q) = (%d, %v), want (%d, %v)",
+					n, q, gotIdx, gotFound, wantIdx, wantFound)
+			}
+		}
+	}
+}
+
+// TestEytzingerFindBelowAllKeys is the minimal, direct repro of the
+// review-reported bug: a query smaller than every key on the page must
+// come back (0, false), the same as FindKey, not the last key's index.
+func TestEytzingerFindBelowAllKeys(t *testing.T) {
+	node := buildBenchNode(38)
+	idx := BuildEytzingerIndex(&node)
+	gotIdx, gotFound := idx.Find([]byte("aaa"))
+	if gotIdx != 0 || gotFound != false {
+		t.Fatalf("Find(below-all) = (%d, %v), want (0, false)", gotIdx, gotFound)
+	}
+}
@@ -0,0 +1,79 @@
+package bnode
+
+import (
+	"github.com/toastsandwich/create-database/consts"
+	"github.com/toastsandwich/create-database/utils"
+)
+
+/*
+LeafInsert/LeafUpdate/NodeAppendRange can build a node bigger than
+BTREE_PAGE_SIZE - they just copy whatever KVs they're told to. Splitting
+the result back down to pages that actually fit on disk is a separate,
+purely byte-level concern, which is why it lives here next to the other
+BNode layout code rather than in the BTree package: it never needs the
+Get/New/Del callbacks, only the node's own bytes.
+*/
+
+// NodeSplit2 splits old into left and right so that right fits in a
+// single page; left and right must already be allocated big enough to
+// receive their share of old's KVs (right is always <= one page, left
+// may still be oversized if a handful of keys account for most of
+// old's bytes - NodeSplit3 handles that case by splitting left again).
+func NodeSplit2(left, right, old BNode) {
+	utils.Assert(old.NKeys() >= 2)
+
+	nleft := old.NKeys() / 2
+	leftBytes := func() uint16 {
+		return consts.HEADER + 8*nleft + 2*nleft + old.GetOffset(nleft)
+	}
+	for leftBytes() > consts.BTREE_PAGE_SIZE {
+		nleft--
+	}
+	utils.Assert(nleft >= 1)
+
+	rightBytes := func() uint16 {
+		return old.Nbyte() - leftBytes() + consts.HEADER
+	}
+	for rightBytes() > consts.BTREE_PAGE_SIZE {
+		nleft++
+	}
+	utils.Assert(nleft < old.NKeys())
+	nright := old.NKeys() - nleft
+
+	left.SetHeader(old.BType(), nleft)
+	right.SetHeader(old.BType(), nright)
+	NodeAppendRange(left, old, 0, 0, nleft)
+	NodeAppendRange(right, old, 0, nleft, nright)
+	// right is guaranteed <= BTREE_PAGE_SIZE by the loop above; left
+	// may not be, and it is up to the caller (NodeSplit3) to split it
+	// again if so.
+}
+
+// NodeSplit3 splits old into 1, 2 or 3 pages that each fit within
+// BTREE_PAGE_SIZE - a single oversized node can always be brought
+// under the limit in at most two splits, since NodeSplit2 already
+// guarantees its right half fits. It returns how many of the 3 slots
+// are populated.
+func NodeSplit3(old BNode) (uint16, [3]BNode) {
+	if old.Nbyte() <= consts.BTREE_PAGE_SIZE {
+		old.Data = old.Data[:consts.BTREE_PAGE_SIZE]
+		return 1, [3]BNode{old}
+	}
+
+	left := BNode{Data: make([]byte, 2*consts.BTREE_PAGE_SIZE)} // might be split again
+	right := BNode{Data: make([]byte, consts.BTREE_PAGE_SIZE)}
+	NodeSplit2(left, right, old)
+	if left.Nbyte() <= consts.BTREE_PAGE_SIZE {
+		left.Data = left.Data[:consts.BTREE_PAGE_SIZE]
+		return 2, [3]BNode{left, right}
+	}
+
+	// left is still too big: split it once more, which is always
+	// enough since a page can hold at most BTREE_PAGE_SIZE worth of
+	// single-KV nodes and left started at <= 2*BTREE_PAGE_SIZE.
+	leftleft := BNode{Data: make([]byte, consts.BTREE_PAGE_SIZE)}
+	middle := BNode{Data: make([]byte, consts.BTREE_PAGE_SIZE)}
+	NodeSplit2(leftleft, middle, left)
+	utils.Assert(leftleft.Nbyte() <= consts.BTREE_PAGE_SIZE)
+	return 3, [3]BNode{leftleft, middle, right}
+}
@@ -1,6 +1,8 @@
 package btree
 
 import (
+	"fmt"
+
 	"github.com/toastsandwich/create-database/consts"
 	bnode "github.com/toastsandwich/create-database/internal/BNode"
 	"github.com/toastsandwich/create-database/utils"
@@ -12,10 +14,52 @@ disk pages instead of in-memory nodes. We’ll add some callbacks to abstract aw
 aspect so that our data structure code remains pure data structure code.
 */
 type BTree struct {
-	Root uint64                   //pointer to non zero page number
-	Get  func(uint64) bnode.BNode //derefence a pointer
-	New  func(bnode.BNode) uint64 //allocate a new page
-	Del  func(uint64)             //deallocate a a page
+	Root    uint64                   //pointer to non zero page number
+	PageGet func(uint64) bnode.BNode //dereference a pointer; named to not collide with the public Get(key) lookup in get.go
+	New     func(bnode.BNode) uint64 //allocate a new page
+	Del     func(uint64)             //deallocate a a page
+
+	// CommitHook, if set, is called by Commit once every page touched by
+	// the transaction has been staged through New, with the new root as
+	// it should become durable. A pager wires this to its own meta-page
+	// swap so the root only ever changes after an fsync makes the new
+	// pages crash-safe.
+	CommitHook func(root uint64) error
+
+	inTx      bool
+	txRoot    uint64 // Root as of Begin, restored on Rollback
+	realNew   func(bnode.BNode) uint64
+	realDel   func(uint64)
+	allocated []uint64 // pages allocated (via New) since Begin
+	freed     []uint64 // pages handed to Del since Begin, not yet reclaimed
+
+	nextLSN uint64 // last page LSN handed out by alloc; in-memory only, resets on process restart
+}
+
+// get dereferences ptr via PageGet and verifies the checksum stamped
+// in its header, panicking on a mismatch - every BNode page the tree
+// itself walks goes through here rather than PageGet directly, so a
+// torn write is caught before the rest of the tree ever sees it.
+// Overflow pages aren't BNode-formatted and bypass this (see
+// bnode.GetLargeVal, called with PageGet directly).
+func (t *BTree) get(ptr uint64) bnode.BNode {
+	n := t.PageGet(ptr)
+	if !n.VerifyChecksum() {
+		panic(fmt.Sprintf("btree: checksum mismatch on page %d (torn write?)", ptr))
+	}
+	return n
+}
+
+// alloc stamps node with the next page LSN and its checksum, then
+// hands it to New - every BNode page that becomes reachable from the
+// tree goes through here rather than New directly. Overflow pages
+// aren't BNode-formatted and bypass this (see bnode.PutLargeVal,
+// called with New directly).
+func (t *BTree) alloc(node bnode.BNode) uint64 {
+	t.nextLSN++
+	node.SetLSN(t.nextLSN)
+	node.SetChecksum()
+	return t.New(node)
 }
 
 /*
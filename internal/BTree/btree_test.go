@@ -0,0 +1,141 @@
+package btree
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/toastsandwich/create-database/consts"
+	bnode "github.com/toastsandwich/create-database/internal/BNode"
+)
+
+// memPages is the simplest possible backing store for a BTree in
+// tests: an in-memory map keyed by an ever-increasing page number,
+// with no persistence and no free-list reuse. It exists purely so
+// these tests can exercise the tree's own logic (split/merge,
+// transactions, overflow, cursors) without dragging in a real pager.
+type memPages struct {
+	pages map[uint64][]byte
+	next  uint64
+}
+
+func newMemPages() *memPages {
+	return &memPages{pages: map[uint64][]byte{}, next: 1}
+}
+
+func (m *memPages) get(ptr uint64) bnode.BNode {
+	data, ok := m.pages[ptr]
+	if !ok {
+		panic(fmt.Sprintf("memPages: no such page %d", ptr))
+	}
+	return bnode.BNode{Data: data}
+}
+
+func (m *memPages) new(node bnode.BNode) uint64 {
+	m.next++
+	ptr := m.next
+	m.pages[ptr] = node.Data
+	return ptr
+}
+
+func (m *memPages) del(ptr uint64) {
+	delete(m.pages, ptr)
+}
+
+// newTestTree returns a BTree backed by a fresh memPages store.
+func newTestTree() *BTree {
+	m := newMemPages()
+	return &BTree{PageGet: m.get, New: m.new, Del: m.del}
+}
+
+// TestUpsertDeleteGetAgainstReferenceMap drives a tree and a plain Go
+// map through the same random sequence of upserts and deletes, and
+// checks Get agrees with the map after every step - this is the
+// closest thing to an exhaustive correctness check for
+// treeInsert/treeDelete/split/merge without knowing their internals.
+func TestUpsertDeleteGetAgainstReferenceMap(t *testing.T) {
+	tree := newTestTree()
+	reference := map[string]string{}
+	rng := rand.New(rand.NewSource(1))
+
+	const ops = 5000
+	const keySpace = 200
+	for i := 0; i < ops; i++ {
+		key := fmt.Sprintf("key-%03d", rng.Intn(keySpace))
+		if rng.Intn(4) == 0 {
+			wantOK := false
+			if _, ok := reference[key]; ok {
+				wantOK = true
+				delete(reference, key)
+			}
+			if gotOK := tree.Delete([]byte(key)); gotOK != wantOK {
+				t.Fatalf("op %d: Delete(%q) = %v, want %v", i, key, gotOK, wantOK)
+			}
+			continue
+		}
+		val := fmt.Sprintf("val-%d-%d", i, rng.Intn(1000))
+		if err := tree.Upsert([]byte(key), []byte(val)); err != nil {
+			t.Fatalf("op %d: Upsert(%q): %v", i, key, err)
+		}
+		reference[key] = val
+	}
+
+	for key, want := range reference {
+		got, ok := tree.Get([]byte(key))
+		if !ok {
+			t.Fatalf("Get(%q) missing, want %q", key, want)
+		}
+		if string(got) != want {
+			t.Fatalf("Get(%q) = %q, want %q", key, got, want)
+		}
+	}
+	for i := 0; i < keySpace; i++ {
+		key := fmt.Sprintf("key-%03d", i)
+		if _, inRef := reference[key]; inRef {
+			continue
+		}
+		if _, ok := tree.Get([]byte(key)); ok {
+			t.Fatalf("Get(%q) found a key that was deleted from the reference map", key)
+		}
+	}
+}
+
+// TestSplitAndMergeAcrossMultipleLevels inserts enough keys that the
+// root must split into multiple levels, then deletes almost all of
+// them back out, forcing merges all the way back down - Get must stay
+// correct at every step.
+func TestSplitAndMergeAcrossMultipleLevels(t *testing.T) {
+	tree := newTestTree()
+
+	const n = 10000
+	for i := 0; i < n; i++ {
+		key := fmt.Sprintf("key-%06d", i)
+		if err := tree.Upsert([]byte(key), []byte(key)); err != nil {
+			t.Fatalf("Upsert(%q): %v", key, err)
+		}
+	}
+	if tree.Root == 0 {
+		t.Fatal("tree should have a root after inserting many keys")
+	}
+	// a single-level tree couldn't possibly hold n keys this large on
+	// 4KB pages, so the root must itself be an internal node by now.
+	if root := tree.get(tree.Root); root.BType() != consts.BNODE_BNODE {
+		t.Fatalf("root BType = %d, want an internal node after %d inserts", root.BType(), n)
+	}
+
+	for i := 0; i < n; i++ {
+		key := fmt.Sprintf("key-%06d", i)
+		if !tree.Delete([]byte(key)) {
+			t.Fatalf("Delete(%q) = false, want true", key)
+		}
+		if _, ok := tree.Get([]byte(key)); ok {
+			t.Fatalf("Get(%q) still found after Delete", key)
+		}
+	}
+	// Root never reverts to 0 once the tree has existed (see Upsert's
+	// empty-key sentinel), but after deleting every real key it should
+	// have merged all the way back down to a single leaf.
+	if root := tree.get(tree.Root); root.BType() != consts.BNODE_BLEAF {
+		t.Fatalf("root BType = %d, want a single leaf once every key has been deleted", root.BType())
+	}
+}
@@ -0,0 +1,231 @@
+package btree
+
+import (
+	"bytes"
+
+	"github.com/toastsandwich/create-database/consts"
+	bnode "github.com/toastsandwich/create-database/internal/BNode"
+)
+
+/*
+A Cursor walks the tree without re-descending from the root on every
+step, the way bcachefs's btree_node_iter (or indexes/btree's prefix
+iterator) does: it keeps a stack of (node, idx) frames, one per level
+from the root down to the leaf it's currently positioned in. Moving to
+the next/previous key only has to climb as far up that stack as the
+current leaf's edge, bump the parent's index, and walk back down the
+new child - it never has to touch pages outside the path between the
+old and new position.
+
+Every frame's idx means "the child/entry this level is currently
+inside". idx is allowed one step outside a node's [0, NKeys()) range at
+the root frame - idx == NKeys() past the last key, or idx == -1 before
+the first - so that running off either end of the tree leaves the
+cursor somewhere Next()/Prev() can still recover the last/first entry
+from, rather than discarding the whole stack. The one other frame that
+can hold idx == -1 is the leftmost leaf, where Prev leaves it after
+stepping back onto the empty-key sentinel.
+*/
+
+type frame struct {
+	node bnode.BNode
+	idx  int
+}
+
+// Cursor is positioned at a single KV pair, or just outside one edge of
+// the tree once Valid() returns false. It is only ever created via
+// Seek or SeekPrefix.
+type Cursor struct {
+	tree   *BTree
+	stack  []frame
+	prefix []byte // if set (SeekPrefix), Valid() stops once Key() leaves the prefix
+}
+
+// Seek positions a cursor at the first key >= key (or just past the
+// end of the tree if there is none).
+func (t *BTree) Seek(key []byte) *Cursor {
+	c := &Cursor{tree: t}
+	if t.Root == 0 {
+		return c
+	}
+
+	node := t.get(t.Root)
+	for {
+		idx, found := node.FindKey(key)
+		if node.BType() == consts.BNODE_BLEAF {
+			li := int(idx)
+			if !found {
+				li++ // FindKey gives the floor; Seek wants the ceiling
+			}
+			c.stack = append(c.stack, frame{node, li})
+			break
+		}
+		c.stack = append(c.stack, frame{node, int(idx)})
+		node = t.get(node.GetPtr(idx))
+	}
+
+	c.advance() // the leaf's ceiling idx may have run past its last key
+	if c.isDummy() {
+		// only reachable when key itself was empty: skip the sentinel
+		c.stack[len(c.stack)-1].idx++
+		c.advance()
+	}
+	return c
+}
+
+// SeekPrefix is Seek(prefix), except the returned cursor reports
+// itself invalid as soon as Key() stops starting with prefix, so a
+// plain `for c := tree.SeekPrefix(p); c.Valid(); c.Next()` loop visits
+// exactly the matching keys.
+func (t *BTree) SeekPrefix(prefix []byte) *Cursor {
+	c := t.Seek(prefix)
+	c.prefix = prefix
+	return c
+}
+
+// Range calls fn for every key k with lo <= k < hi (hi == nil means no
+// upper bound), stopping early if fn returns false.
+func (t *BTree) Range(lo, hi []byte, fn func(k, v []byte) bool) {
+	for c := t.Seek(lo); c.Valid(); c.Next() {
+		k := c.Key()
+		if hi != nil && bytes.Compare(k, hi) >= 0 {
+			return
+		}
+		if !fn(k, c.Value()) {
+			return
+		}
+	}
+}
+
+// Valid reports whether the cursor is positioned at a real KV pair.
+func (c *Cursor) Valid() bool {
+	if len(c.stack) == 0 {
+		return false
+	}
+	f := c.stack[len(c.stack)-1]
+	if f.idx < 0 || f.idx >= int(f.node.NKeys()) {
+		return false
+	}
+	return c.prefix == nil || bytes.HasPrefix(f.node.GetKey(uint16(f.idx)), c.prefix)
+}
+
+// Key returns the key at the cursor's current position.
+func (c *Cursor) Key() []byte {
+	f := c.stack[len(c.stack)-1]
+	return f.node.GetKey(uint16(f.idx))
+}
+
+// Value returns the value at the cursor's current position,
+// reassembling it first if it was stored out-of-line (see
+// bnode.GetLargeVal).
+func (c *Cursor) Value() []byte {
+	f := c.stack[len(c.stack)-1]
+	idx := uint16(f.idx)
+	if f.node.IsOverflowVal(idx) {
+		return bnode.GetLargeVal(c.tree.PageGet, f.node.GetVal(idx))
+	}
+	return f.node.GetVal(idx)
+}
+
+// Next moves the cursor to the next key in order, or just past the end
+// of the tree (Valid() becomes false) if there isn't one.
+func (c *Cursor) Next() {
+	if len(c.stack) == 0 {
+		return
+	}
+	c.stack[len(c.stack)-1].idx++
+	c.advance()
+	if c.isDummy() {
+		c.stack[len(c.stack)-1].idx++
+		c.advance()
+	}
+}
+
+// Prev moves the cursor to the previous key in order, or just before
+// the start of the tree if there isn't one.
+func (c *Cursor) Prev() {
+	if !c.retreat() {
+		return
+	}
+	c.descendRightmost()
+	if c.isDummy() {
+		// walked back onto the leading sentinel: nothing comes before it
+		c.stack[len(c.stack)-1].idx = -1
+	}
+}
+
+// advance restores the stack invariant after the bottom frame's idx
+// has been pushed past its node's last key (by Next, or by Seek's leaf
+// ceiling running off the page): it pops exhausted frames, bumping
+// each parent's idx as it climbs past that child, then walks back down
+// to a leaf from wherever it lands. The root frame is never popped, so
+// running off the right edge of the whole tree still leaves Prev() a
+// path back to the last entry.
+func (c *Cursor) advance() {
+	for len(c.stack) > 1 {
+		top := &c.stack[len(c.stack)-1]
+		if top.idx < int(top.node.NKeys()) {
+			c.descendLeftmost()
+			return
+		}
+		c.stack = c.stack[:len(c.stack)-1]
+		c.stack[len(c.stack)-1].idx++
+	}
+	if len(c.stack) == 1 && c.stack[0].idx < int(c.stack[0].node.NKeys()) {
+		c.descendLeftmost()
+	}
+}
+
+// retreat is advance's mirror image for moving backwards: a frame with
+// idx == 0 has no earlier sibling at its level, so it's popped outright
+// rather than bumped - except the root frame, which is left at idx ==
+// -1 instead so Next() can still recover the first entry. Returns
+// false if the cursor is (or becomes) positioned before the tree's
+// first key.
+func (c *Cursor) retreat() bool {
+	for len(c.stack) > 1 {
+		top := &c.stack[len(c.stack)-1]
+		if top.idx > 0 {
+			top.idx--
+			return true
+		}
+		c.stack = c.stack[:len(c.stack)-1]
+	}
+	if len(c.stack) == 1 {
+		if c.stack[0].idx > 0 {
+			c.stack[0].idx--
+			return true
+		}
+		c.stack[0].idx = -1
+	}
+	return false
+}
+
+func (c *Cursor) descendLeftmost() {
+	for len(c.stack) > 0 {
+		top := c.stack[len(c.stack)-1]
+		if top.node.BType() == consts.BNODE_BLEAF {
+			return
+		}
+		child := c.tree.get(top.node.GetPtr(uint16(top.idx)))
+		c.stack = append(c.stack, frame{child, 0})
+	}
+}
+
+func (c *Cursor) descendRightmost() {
+	for len(c.stack) > 0 {
+		top := c.stack[len(c.stack)-1]
+		if top.node.BType() == consts.BNODE_BLEAF {
+			return
+		}
+		child := c.tree.get(top.node.GetPtr(uint16(top.idx)))
+		c.stack = append(c.stack, frame{child, int(child.NKeys()) - 1})
+	}
+}
+
+// isDummy reports whether the cursor is sitting on the empty-key
+// sentinel every tree's leftmost leaf starts with (see Upsert) - it is
+// never a real entry and should be transparently skipped over.
+func (c *Cursor) isDummy() bool {
+	return c.Valid() && len(c.Key()) == 0
+}
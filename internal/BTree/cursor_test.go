@@ -0,0 +1,64 @@
+package btree
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// TestRangeMatchesSortedKeys inserts a batch of keys in random order
+// and checks that Range (and therefore Seek/Next under it) visits
+// every key in sorted order with no gaps, duplicates, or wrong values.
+func TestRangeMatchesSortedKeys(t *testing.T) {
+	tree := newTestTree()
+	rng := rand.New(rand.NewSource(2))
+
+	const n = 2000
+	want := make([]string, n)
+	order := rng.Perm(n)
+	for _, i := range order {
+		key := fmt.Sprintf("key-%05d", i)
+		val := fmt.Sprintf("val-%05d", i)
+		if err := tree.Upsert([]byte(key), []byte(val)); err != nil {
+			t.Fatalf("Upsert(%q): %v", key, err)
+		}
+		want[i] = key
+	}
+	sort.Strings(want)
+
+	var got []string
+	tree.Range(nil, nil, func(k, v []byte) bool {
+		got = append(got, string(k))
+		wantVal := "val" + string(k)[3:] // key-NNNNN -> val-NNNNN
+		if string(v) != wantVal {
+			t.Fatalf("Range: value for %q = %q, want %q", k, v, wantVal)
+		}
+		return true
+	})
+
+	if len(got) != len(want) {
+		t.Fatalf("Range visited %d keys, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Range[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	// a bounded Range should match a slice of the same sorted keys.
+	lo, hi := n/4, 3*n/4
+	var bounded []string
+	tree.Range([]byte(want[lo]), []byte(want[hi]), func(k, v []byte) bool {
+		bounded = append(bounded, string(k))
+		return true
+	})
+	if len(bounded) != hi-lo {
+		t.Fatalf("bounded Range visited %d keys, want %d", len(bounded), hi-lo)
+	}
+	for i, k := range bounded {
+		if k != want[lo+i] {
+			t.Fatalf("bounded Range[%d] = %q, want %q", i, k, want[lo+i])
+		}
+	}
+}
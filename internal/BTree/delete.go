@@ -0,0 +1,138 @@
+package btree
+
+import (
+	"github.com/toastsandwich/create-database/consts"
+	bnode "github.com/toastsandwich/create-database/internal/BNode"
+	"github.com/toastsandwich/create-database/utils"
+)
+
+/*
+treeDelete is the mirror image of treeInsert: it descends to the leaf
+holding key, removes it, and on the way back up checks whether the
+child it just shrank is now small enough to fold into a sibling -
+merging early keeps the tree from accumulating pages that are mostly
+empty after a long run of deletes, the same way NodeSplit3 keeps it
+from growing pages past the limit after a long run of inserts.
+*/
+
+// Delete removes key, reporting whether it was present.
+func (t *BTree) Delete(key []byte) bool {
+	utils.Assert(len(key) != 0)
+	utils.Assert(len(key) <= consts.BTREE_MAX_KEY_SIZE)
+	if t.Root == 0 {
+		return false
+	}
+
+	updated := treeDelete(t, t.get(t.Root), key)
+	if len(updated.Data) == 0 {
+		return false // key not found, nothing changed
+	}
+	t.Del(t.Root)
+
+	if updated.BType() == consts.BNODE_BNODE && updated.NKeys() == 1 {
+		// the root shrank to a single child: drop a level instead of
+		// keeping an internal node around that just points at itself
+		t.Root = updated.GetPtr(0)
+	} else {
+		t.Root = t.alloc(updated)
+	}
+	return true
+}
+
+// treeDelete returns the updated node with key removed, or a
+// zero-value BNode (nil Data) if key was not found anywhere under
+// node.
+func treeDelete(t *BTree, node bnode.BNode, key []byte) bnode.BNode {
+	idx, found := node.FindKey(key)
+	switch node.BType() {
+	case consts.BNODE_BLEAF:
+		if !found {
+			return bnode.BNode{}
+		}
+		if node.IsOverflowVal(idx) {
+			bnode.FreeLargeVal(t.Del, node.GetVal(idx))
+		}
+		new := bnode.BNode{Data: make([]byte, consts.BTREE_PAGE_SIZE)}
+		bnode.LeafDelete(new, node, idx)
+		return new
+	case consts.BNODE_BNODE:
+		return nodeDelete(t, node, idx, key)
+	default:
+		panic("btree: bad node type")
+	}
+}
+
+// nodeDelete deletes key from node's child at idx and, if that child
+// shrank enough, merges it with a sibling before writing the result
+// back into node.
+func nodeDelete(t *BTree, node bnode.BNode, idx uint16, key []byte) bnode.BNode {
+	kptr := node.GetPtr(idx)
+	updated := treeDelete(t, t.get(kptr), key)
+	if len(updated.Data) == 0 {
+		return bnode.BNode{} // not found
+	}
+	t.Del(kptr)
+
+	new := bnode.BNode{Data: make([]byte, consts.BTREE_PAGE_SIZE)}
+	mergeDir, sibling := shouldMerge(t, node, idx, updated)
+	switch {
+	case mergeDir < 0: // merge with the left sibling
+		merged := bnode.BNode{Data: make([]byte, consts.BTREE_PAGE_SIZE)}
+		nodeMerge(merged, sibling, updated)
+		t.Del(node.GetPtr(idx - 1))
+		nodeReplace2Kid(new, node, idx-1, t.alloc(merged), merged.GetKey(0))
+	case mergeDir > 0: // merge with the right sibling
+		merged := bnode.BNode{Data: make([]byte, consts.BTREE_PAGE_SIZE)}
+		nodeMerge(merged, updated, sibling)
+		t.Del(node.GetPtr(idx + 1))
+		nodeReplace2Kid(new, node, idx, t.alloc(merged), merged.GetKey(0))
+	case updated.NKeys() == 0:
+		utils.Assert(node.NKeys() == 1 && idx == 0) // only child, no sibling to merge with
+		new.SetHeader(consts.BNODE_BNODE, 0)
+	default: // no merge, just splice the shrunk child back in
+		nodeReplaceKidN(t, new, node, idx, updated)
+	}
+	return new
+}
+
+// shouldMerge decides whether updated (node's child at idx, after a
+// delete) is small enough that it should be folded into a sibling
+// rather than left as its own page: <0 for the left sibling, >0 for
+// the right, 0 if neither helps.
+func shouldMerge(t *BTree, node bnode.BNode, idx uint16, updated bnode.BNode) (int, bnode.BNode) {
+	if updated.Nbyte() > consts.BTREE_PAGE_SIZE/4 {
+		return 0, bnode.BNode{}
+	}
+
+	if idx > 0 {
+		sibling := t.get(node.GetPtr(idx - 1))
+		merged := sibling.Nbyte() + updated.Nbyte() - consts.HEADER
+		if merged <= consts.BTREE_PAGE_SIZE {
+			return -1, sibling
+		}
+	}
+	if idx+1 < node.NKeys() {
+		sibling := t.get(node.GetPtr(idx + 1))
+		merged := sibling.Nbyte() + updated.Nbyte() - consts.HEADER
+		if merged <= consts.BTREE_PAGE_SIZE {
+			return +1, sibling
+		}
+	}
+	return 0, bnode.BNode{}
+}
+
+// nodeMerge concatenates left and right's KVs into a single new node.
+func nodeMerge(new, left, right bnode.BNode) {
+	new.SetHeader(left.BType(), left.NKeys()+right.NKeys())
+	bnode.NodeAppendRange(new, left, 0, 0, left.NKeys())
+	bnode.NodeAppendRange(new, right, left.NKeys(), 0, right.NKeys())
+}
+
+// nodeReplace2Kid rebuilds old with the two children at idx and idx+1
+// collapsed into the single already-merged page ptr.
+func nodeReplace2Kid(new, old bnode.BNode, idx uint16, ptr uint64, key []byte) {
+	new.SetHeader(consts.BNODE_BNODE, old.NKeys()-1)
+	bnode.NodeAppendRange(new, old, 0, 0, idx)
+	bnode.NodeAppendKV(new, idx, ptr, key, nil, false)
+	bnode.NodeAppendRange(new, old, idx+1, idx+2, old.NKeys()-(idx+2))
+}
@@ -0,0 +1,35 @@
+package btree
+
+import (
+	"github.com/toastsandwich/create-database/consts"
+	bnode "github.com/toastsandwich/create-database/internal/BNode"
+)
+
+// Get looks up key and returns its value, descending from Root through
+// internal nodes (verifying each page's checksum as it goes, see
+// BTree.get) until it either lands on a matching leaf entry or runs
+// out of tree.
+func (t *BTree) Get(key []byte) ([]byte, bool) {
+	if t.Root == 0 {
+		return nil, false
+	}
+	return treeGet(t, t.get(t.Root), key)
+}
+
+func treeGet(t *BTree, node bnode.BNode, key []byte) ([]byte, bool) {
+	idx, found := node.FindKey(key)
+	switch node.BType() {
+	case consts.BNODE_BLEAF:
+		if !found {
+			return nil, false
+		}
+		if node.IsOverflowVal(idx) {
+			return bnode.GetLargeVal(t.PageGet, node.GetVal(idx)), true
+		}
+		return node.GetVal(idx), true
+	case consts.BNODE_BNODE:
+		return treeGet(t, t.get(node.GetPtr(idx)), key)
+	default:
+		panic("btree: bad node type")
+	}
+}
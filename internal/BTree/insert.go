@@ -0,0 +1,148 @@
+package btree
+
+import (
+	"fmt"
+
+	"github.com/toastsandwich/create-database/consts"
+	bnode "github.com/toastsandwich/create-database/internal/BNode"
+)
+
+/*
+treeInsert and nodeInsert are what turn LeafInsert/LeafUpdate and
+NodeSplit2/NodeSplit3 into a balanced tree: they walk down to the right
+leaf, insert or update there, and on the way back up replace each
+ancestor's single child pointer with however many pages (1-3) that
+child became after splitting. Every page on the path is copy-on-write -
+the old page is only freed (via Del) once the new one has taken its
+place in the node being built one level up.
+*/
+
+func checkLimit(key, val []byte) error {
+	if len(key) == 0 {
+		return fmt.Errorf("btree: empty key")
+	}
+	if len(key) > consts.BTREE_MAX_KEY_SIZE {
+		return fmt.Errorf("btree: key too large (%d > %d)", len(key), consts.BTREE_MAX_KEY_SIZE)
+	}
+	// values past the overflow threshold are chained across their own
+	// pages (see encodeVal), but the pointer array that replaces them
+	// inline still has to fit within BTREE_MAX_VAL_SIZE like any other
+	// value.
+	if bnode.OverflowPageCount(len(val))*8 > consts.BTREE_MAX_VAL_SIZE {
+		return fmt.Errorf("btree: value too large (%d bytes)", len(val))
+	}
+	return nil
+}
+
+// encodeVal returns what should actually be stored inline for val:
+// val itself, unless it is bigger than BTREE_VAL_OVERFLOW_THRESHOLD,
+// in which case it's chained across overflow pages via PutLargeVal and
+// the returned bytes are the pointer array to those pages instead.
+func encodeVal(t *BTree, val []byte) (encoded []byte, overflow bool) {
+	if len(val) <= consts.BTREE_VAL_OVERFLOW_THRESHOLD {
+		return val, false
+	}
+	return bnode.PutLargeVal(t.New, val), true
+}
+
+// Upsert inserts key/val, replacing the value in place if key already
+// exists.
+func (t *BTree) Upsert(key, val []byte) error {
+	if err := checkLimit(key, val); err != nil {
+		return err
+	}
+
+	if t.Root == 0 {
+		// the empty tree: a single leaf with a dummy empty-key entry
+		// ahead of the real one, so FindKey always has a floor to
+		// return and never has to special-case an empty node.
+		root := bnode.BNode{Data: make([]byte, consts.BTREE_PAGE_SIZE)}
+		root.SetHeader(consts.BNODE_BLEAF, 2)
+		bnode.NodeAppendKV(root, 0, 0, nil, nil, false)
+		encVal, overflow := encodeVal(t, val)
+		bnode.NodeAppendKV(root, 1, 0, key, encVal, overflow)
+		t.Root = t.alloc(root)
+		return nil
+	}
+
+	oldRoot := t.Root
+	node := treeInsert(t, t.get(t.Root), key, val)
+	nsplit, split := bnode.NodeSplit3(node)
+	if nsplit == 1 {
+		t.Root = t.alloc(split[0])
+	} else {
+		root := bnode.BNode{Data: make([]byte, consts.BTREE_PAGE_SIZE)}
+		root.SetHeader(consts.BNODE_BNODE, nsplit)
+		for i, kid := range split[:nsplit] {
+			bnode.NodeAppendKV(root, uint16(i), t.alloc(kid), kid.GetKey(0), nil, false)
+		}
+		t.Root = t.alloc(root)
+	}
+	t.Del(oldRoot)
+	return nil
+}
+
+// Insert adds key/val, failing if key is already present - callers
+// that want an overwrite should use Upsert instead.
+func (t *BTree) Insert(key, val []byte) error {
+	if err := checkLimit(key, val); err != nil {
+		return err
+	}
+	if _, ok := t.Get(key); ok {
+		return fmt.Errorf("btree: key already exists")
+	}
+	return t.Upsert(key, val)
+}
+
+// treeInsert returns the (possibly oversized) node that results from
+// inserting key/val somewhere under node; the caller is responsible
+// for splitting it back down via NodeSplit3.
+func treeInsert(t *BTree, node bnode.BNode, key, val []byte) bnode.BNode {
+	new := bnode.BNode{Data: make([]byte, 2*consts.BTREE_PAGE_SIZE)}
+
+	idx, found := node.FindKey(key)
+	switch node.BType() {
+	case consts.BNODE_BLEAF:
+		if found && node.IsOverflowVal(idx) {
+			// the key already had an out-of-line value; it's being
+			// replaced, so its old overflow chain is now garbage
+			bnode.FreeLargeVal(t.Del, node.GetVal(idx))
+		}
+		encVal, overflow := encodeVal(t, val)
+		if found {
+			bnode.LeafUpdate(new, node, idx, key, encVal, overflow)
+		} else {
+			bnode.LeafInsert(new, node, idx+1, key, encVal, overflow)
+		}
+	case consts.BNODE_BNODE:
+		nodeInsert(t, new, node, idx, key, val)
+	default:
+		panic("btree: bad node type")
+	}
+	return new
+}
+
+// nodeInsert descends into node's child at idx, inserts there, splits
+// whatever comes back, and replaces that one child pointer with the
+// 1-3 pages the split produced.
+func nodeInsert(t *BTree, new bnode.BNode, node bnode.BNode, idx uint16, key, val []byte) {
+	kptr := node.GetPtr(idx)
+	kid := treeInsert(t, t.get(kptr), key, val)
+	t.Del(kptr)
+
+	nsplit, split := bnode.NodeSplit3(kid)
+	nodeReplaceKidN(t, new, node, idx, split[:nsplit]...)
+}
+
+// nodeReplaceKidN rebuilds node with the child at idx replaced by
+// kids, allocating a fresh page for each one via New - this is the
+// step that turns a leaf/internal split into a wider parent.
+func nodeReplaceKidN(t *BTree, new bnode.BNode, old bnode.BNode, idx uint16, kids ...bnode.BNode) {
+	inc := uint16(len(kids))
+	new.SetHeader(consts.BNODE_BNODE, old.NKeys()+inc-1)
+	bnode.NodeAppendRange(new, old, 0, 0, idx)
+	for i, kid := range kids {
+		bnode.NodeAppendKV(new, idx+uint16(i), t.alloc(kid), kid.GetKey(0), nil, false)
+	}
+	bnode.NodeAppendRange(new, old, idx+inc, idx+1, old.NKeys()-(idx+1))
+}
@@ -0,0 +1,46 @@
+package btree
+
+import (
+	"testing"
+
+	"github.com/toastsandwich/create-database/consts"
+)
+
+// TestLargeValueOverflowRoundTrip inserts a value well past
+// BTREE_VAL_OVERFLOW_THRESHOLD, long enough to span several overflow
+// pages, and checks it reads back exactly and that deleting the key
+// frees every overflow page it used.
+func TestLargeValueOverflowRoundTrip(t *testing.T) {
+	m := newMemPages()
+	tree := &BTree{PageGet: m.get, New: m.new, Del: m.del}
+
+	big := make([]byte, consts.BTREE_VAL_OVERFLOW_THRESHOLD*5)
+	for i := range big {
+		big[i] = byte(i)
+	}
+	if err := tree.Upsert([]byte("bigkey"), big); err != nil {
+		t.Fatalf("Upsert large value: %v", err)
+	}
+	pagesAfterInsert := len(m.pages)
+
+	got, ok := tree.Get([]byte("bigkey"))
+	if !ok {
+		t.Fatal("Get(bigkey) not found")
+	}
+	if len(got) != len(big) {
+		t.Fatalf("Get(bigkey) returned %d bytes, want %d", len(got), len(big))
+	}
+	for i := range big {
+		if got[i] != big[i] {
+			t.Fatalf("Get(bigkey)[%d] = %d, want %d", i, got[i], big[i])
+		}
+	}
+
+	if !tree.Delete([]byte("bigkey")) {
+		t.Fatal("Delete(bigkey) = false, want true")
+	}
+	if len(m.pages) >= pagesAfterInsert {
+		t.Fatalf("page count after delete = %d, want fewer than %d (overflow pages should be freed)",
+			len(m.pages), pagesAfterInsert)
+	}
+}
@@ -0,0 +1,95 @@
+package btree
+
+import (
+	bnode "github.com/toastsandwich/create-database/internal/BNode"
+	"github.com/toastsandwich/create-database/utils"
+)
+
+/*
+The copy-on-write machinery (LeafInsert, NodeAppendRange, and friends
+built on top of them) never mutates a page in place: it always builds a
+new page via New and leaves the old one untouched. That alone makes the
+tree immutable, but it is not yet crash-safe - if the process dies
+halfway through an update, New may have written some fresh pages while
+the old root pointer (and therefore the whole previous tree) is still
+perfectly intact on disk, or it may not. Begin/Commit/Rollback turn that
+property into an actual transaction:
+
+  - Begin snapshots Root and starts tracking every page New hands out.
+  - Commit hands the old pages the transaction replaced to the real
+    Del, then asks CommitHook to durably swap the root to the page
+    built during the transaction (fsync, then atomic root write); the
+    updated free list and the new root become durable in that same
+    swap. A crash before the swap leaves the old root - and the
+    pages it reaches - untouched; a crash after it leaves the new root
+    and its pages durable. Either way there is no torn state.
+  - Rollback discards the transaction: every page it allocated is
+    handed back to the free list and Root reverts to its pre-Begin
+    value, so nothing it built is ever reachable.
+*/
+
+// Begin starts a transaction. New and Del are wrapped so their effects
+// can be undone (Rollback) or made durable (Commit) as a unit.
+func (t *BTree) Begin() {
+	utils.Assert(!t.inTx)
+	t.inTx = true
+	t.txRoot = t.Root
+	t.realNew, t.realDel = t.New, t.Del
+	t.allocated, t.freed = nil, nil
+
+	t.New = func(n bnode.BNode) uint64 {
+		ptr := t.realNew(n)
+		t.allocated = append(t.allocated, ptr)
+		return ptr
+	}
+	t.Del = func(ptr uint64) {
+		t.freed = append(t.freed, ptr)
+	}
+}
+
+// Commit durably swaps the root to the tree built since Begin, having
+// first freed the pages it replaced, so both land in the same durable
+// batch. Only valid pages that New staged during this transaction may
+// end up reachable from Root - Commit does not build anything itself,
+// it just finalizes what Insert/Delete already built.
+func (t *BTree) Commit() error {
+	utils.Assert(t.inTx)
+	// realDel (Pager.Del) only pushes onto the in-memory free list and
+	// stages the free-list node pages it touches in the pager - nothing
+	// durable happens until CommitHook flushes and swaps the meta page.
+	// Doing this before CommitHook means that flush/fsync/swap covers
+	// the updated free list and the new root together: either both
+	// become durable, or (CommitHook never runs, or fails before the
+	// meta swap) neither does, since the old meta page - still the one
+	// a later Open adopts - never mentions any of it.
+	for _, ptr := range t.freed {
+		t.realDel(ptr)
+	}
+	if t.CommitHook != nil {
+		if err := t.CommitHook(t.Root); err != nil {
+			return err
+		}
+	}
+	t.endTx()
+	return nil
+}
+
+// Rollback discards every page allocated since Begin and restores Root
+// to its pre-transaction value. Pages passed to Del during the
+// transaction are never actually freed, since they still belong to the
+// root being restored.
+func (t *BTree) Rollback() {
+	utils.Assert(t.inTx)
+	for _, ptr := range t.allocated {
+		t.realDel(ptr)
+	}
+	t.Root = t.txRoot
+	t.endTx()
+}
+
+func (t *BTree) endTx() {
+	t.New, t.Del = t.realNew, t.realDel
+	t.realNew, t.realDel = nil, nil
+	t.allocated, t.freed = nil, nil
+	t.inTx = false
+}
@@ -0,0 +1,104 @@
+package btree
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestCommitAppliesAndRollbackDiscards drives one tree through a mix
+// of committed and rolled-back transactions and checks against a plain
+// Go map: a Commit's writes must all be visible afterward, and a
+// Rollback must leave Get exactly as if it had never happened.
+func TestCommitAppliesAndRollbackDiscards(t *testing.T) {
+	tree := newTestTree()
+	reference := map[string]string{}
+
+	apply := func(key, val string) {
+		tree.Begin()
+		if err := tree.Upsert([]byte(key), []byte(val)); err != nil {
+			t.Fatalf("Upsert(%q): %v", key, err)
+		}
+		if err := tree.Commit(); err != nil {
+			t.Fatalf("Commit: %v", err)
+		}
+		reference[key] = val
+	}
+	discard := func(key, val string) {
+		tree.Begin()
+		if err := tree.Upsert([]byte(key), []byte(val)); err != nil {
+			t.Fatalf("Upsert(%q): %v", key, err)
+		}
+		tree.Rollback()
+	}
+	remove := func(key string) {
+		tree.Begin()
+		if !tree.Delete([]byte(key)) {
+			t.Fatalf("Delete(%q) = false, want true", key)
+		}
+		if err := tree.Commit(); err != nil {
+			t.Fatalf("Commit: %v", err)
+		}
+		delete(reference, key)
+	}
+
+	for i := 0; i < 200; i++ {
+		key := fmt.Sprintf("key-%03d", i)
+		apply(key, fmt.Sprintf("val-%d-v1", i))
+	}
+	for i := 0; i < 200; i += 2 {
+		// overwrite half the keys inside a transaction that gets
+		// rolled back: the pre-transaction value must survive.
+		discard(fmt.Sprintf("key-%03d", i), "should-never-be-visible")
+	}
+	for i := 0; i < 200; i += 3 {
+		remove(fmt.Sprintf("key-%03d", i))
+	}
+
+	for i := 0; i < 200; i++ {
+		key := fmt.Sprintf("key-%03d", i)
+		want, inRef := reference[key]
+		got, ok := tree.Get([]byte(key))
+		if ok != inRef {
+			t.Fatalf("Get(%q) ok = %v, want %v", key, ok, inRef)
+		}
+		if ok && string(got) != want {
+			t.Fatalf("Get(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+// TestRollbackFreesPagesAllocatedSinceBegin checks that a rolled-back
+// transaction doesn't leak the pages it allocated while building its
+// (discarded) tree: the page count after Begin/Upsert/Rollback must
+// return to what it was before Begin.
+func TestRollbackFreesPagesAllocatedSinceBegin(t *testing.T) {
+	m := newMemPages()
+	tree := &BTree{PageGet: m.get, New: m.new, Del: m.del}
+
+	for i := 0; i < 50; i++ {
+		key := fmt.Sprintf("key-%03d", i)
+		if err := tree.Upsert([]byte(key), []byte(key)); err != nil {
+			t.Fatalf("Upsert(%q): %v", key, err)
+		}
+	}
+	pagesBeforeTx := len(m.pages)
+
+	tree.Begin()
+	for i := 50; i < 100; i++ {
+		key := fmt.Sprintf("key-%03d", i)
+		if err := tree.Upsert([]byte(key), []byte(key)); err != nil {
+			t.Fatalf("Upsert(%q): %v", key, err)
+		}
+	}
+	tree.Rollback()
+
+	if len(m.pages) != pagesBeforeTx {
+		t.Fatalf("page count after rollback = %d, want %d (pre-transaction)", len(m.pages), pagesBeforeTx)
+	}
+	for i := 50; i < 100; i++ {
+		key := fmt.Sprintf("key-%03d", i)
+		if _, ok := tree.Get([]byte(key)); ok {
+			t.Fatalf("Get(%q) found after Rollback", key)
+		}
+	}
+}
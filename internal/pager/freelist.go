@@ -0,0 +1,92 @@
+package pager
+
+import (
+	"encoding/binary"
+
+	"github.com/toastsandwich/create-database/consts"
+	"github.com/toastsandwich/create-database/utils"
+)
+
+/*
+The free list is its own tiny linked list of pages, not part of the
+BTree: each node is one page holding a slice of reclaimed page numbers
+plus a pointer to the next node. This is the same idea as bcachefs's
+"free page" bitmap/list and the free-list used by most mmap'd B+ trees
+- freeing a page appends it to the list head (allocating a new
+free-list node only when the head is full or there is none yet), and
+handing one back out is a pop from the head, so both are O(1) and
+don't disturb the BTree itself.
+*/
+type freeList struct {
+	head uint64
+	get  func(uint64) []byte
+	new  func([]byte) uint64
+	use  func(uint64, []byte)
+}
+
+func flnSize(data []byte) uint16 {
+	return binary.LittleEndian.Uint16(data[2:4])
+}
+
+func flnNext(data []byte) uint64 {
+	return binary.LittleEndian.Uint64(data[4:12])
+}
+
+func flnPtr(data []byte, idx int) uint64 {
+	utils.Assert(idx < consts.FREE_LIST_CAP)
+	pos := consts.FREE_LIST_HEADER + 8*idx
+	return binary.LittleEndian.Uint64(data[pos:])
+}
+
+func flnSetHeader(data []byte, size uint16, next uint64) {
+	binary.LittleEndian.PutUint16(data[0:2], consts.BNODE_FREE_LIST)
+	binary.LittleEndian.PutUint16(data[2:4], size)
+	binary.LittleEndian.PutUint64(data[4:12], next)
+}
+
+func flnSetPtr(data []byte, idx int, ptr uint64) {
+	utils.Assert(idx < consts.FREE_LIST_CAP)
+	pos := consts.FREE_LIST_HEADER + 8*idx
+	binary.LittleEndian.PutUint64(data[pos:], ptr)
+}
+
+// Pop reclaims one page from the head of the free list, or 0 if the
+// list is empty so the caller should extend the file instead.
+func (fl *freeList) Pop() uint64 {
+	if fl.head == 0 {
+		return 0
+	}
+	node := fl.get(fl.head)
+	size := flnSize(node)
+	if size > 0 {
+		ptr := flnPtr(node, int(size-1))
+		flnSetHeader(node, size-1, flnNext(node))
+		fl.use(fl.head, node)
+		return ptr
+	}
+	// this node is drained, free it and move on to the next one
+	oldHead := fl.head
+	fl.head = flnNext(node)
+	return oldHead
+}
+
+// Push adds a newly-freed page to the head of the list, allocating a
+// new free-list node when the current head is full.
+func (fl *freeList) Push(ptr uint64) {
+	if fl.head != 0 {
+		node := fl.get(fl.head)
+		size := flnSize(node)
+		if size < consts.FREE_LIST_CAP {
+			flnSetPtr(node, int(size), ptr)
+			flnSetHeader(node, size+1, flnNext(node))
+			fl.use(fl.head, node)
+			return
+		}
+	}
+	// current head is full (or there is none yet): start a new node
+	// that points at the old head
+	data := make([]byte, consts.BTREE_PAGE_SIZE)
+	flnSetHeader(data, 1, fl.head)
+	flnSetPtr(data, 0, ptr)
+	fl.head = fl.new(data)
+}
@@ -0,0 +1,103 @@
+package pager
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+
+	"github.com/toastsandwich/create-database/consts"
+)
+
+/*
+Two meta pages (0 and 1) take the place of a single header so the root
+pointer can be swapped atomically the way LMDB and bcachefs do it:
+commit always writes the *other* page from the one currently active,
+fsyncs, and only then flips which page is active. A crash during that
+write leaves the checksum on the half-written page invalid, so loadMeta
+falls back to the still-intact, still-valid page with the lower
+sequence number - the previous commit - and the tree simply looks like
+the in-flight transaction never happened.
+
+meta page layout: [sig][seq uint64][root uint64][free head uint64][flushed uint64][crc32 uint32]
+*/
+const (
+	metaPageCount = 2
+	metaSeqOff    = 0
+	metaRootOff   = metaSeqOff + 8
+	metaFreeOff   = metaRootOff + 8
+	metaFlushOff  = metaFreeOff + 8
+	metaCrcOff    = metaFlushOff + 8
+)
+
+func metaChecksum(data []byte) uint32 {
+	return crc32.ChecksumIEEE(data[:len(consts.DB_SIG)+metaCrcOff])
+}
+
+// loadMeta reads both meta pages and adopts whichever is valid (sig and
+// checksum match) with the higher sequence number, so that on restart
+// the pager resumes from the last durably committed root.
+func (p *Pager) loadMeta() {
+	var best struct {
+		ok   bool
+		slot int
+		seq  uint64
+	}
+
+	for slot := 0; slot < metaPageCount; slot++ {
+		data := p.pageGetMapped(uint64(slot))
+		sig := len(consts.DB_SIG)
+		if string(data[:sig]) != consts.DB_SIG {
+			continue
+		}
+		crc := binary.LittleEndian.Uint32(data[sig+metaCrcOff:])
+		if crc != metaChecksum(data) {
+			continue // torn write from a crash mid-commit; skip it
+		}
+		seq := binary.LittleEndian.Uint64(data[sig+metaSeqOff:])
+		if !best.ok || seq > best.seq {
+			best.ok, best.slot, best.seq = true, slot, seq
+		}
+	}
+
+	if !best.ok {
+		// brand new file: nothing committed yet
+		p.metaSlot, p.metaSeq = 1, 0
+		return
+	}
+
+	data := p.pageGetMapped(uint64(best.slot))
+	sig := len(consts.DB_SIG)
+	p.Tree.Root = binary.LittleEndian.Uint64(data[sig+metaRootOff:])
+	p.free.head = binary.LittleEndian.Uint64(data[sig+metaFreeOff:])
+	p.page.flushed = binary.LittleEndian.Uint64(data[sig+metaFlushOff:])
+	p.metaSlot, p.metaSeq = best.slot, best.seq
+}
+
+// saveMeta writes root (plus the free-list head and page count) to the
+// meta page that is *not* currently active and fsyncs it, then makes
+// that the active slot. The previously active page is left untouched,
+// so it remains a valid fallback until the next commit overwrites it.
+func (p *Pager) saveMeta(root uint64) error {
+	slot := 1 - p.metaSlot
+	seq := p.metaSeq + 1
+
+	data := make([]byte, consts.BTREE_PAGE_SIZE)
+	sig := len(consts.DB_SIG)
+	copy(data, consts.DB_SIG)
+	binary.LittleEndian.PutUint64(data[sig+metaSeqOff:], seq)
+	binary.LittleEndian.PutUint64(data[sig+metaRootOff:], root)
+	binary.LittleEndian.PutUint64(data[sig+metaFreeOff:], p.free.head)
+	binary.LittleEndian.PutUint64(data[sig+metaFlushOff:], p.page.flushed)
+	binary.LittleEndian.PutUint32(data[sig+metaCrcOff:], metaChecksum(data))
+
+	if _, err := p.fp.WriteAt(data, int64(slot)*consts.BTREE_PAGE_SIZE); err != nil {
+		return fmt.Errorf("pager: WriteAt meta: %w", err)
+	}
+	if err := p.fp.Sync(); err != nil {
+		return fmt.Errorf("pager: Sync meta: %w", err)
+	}
+
+	copy(p.pageGetMapped(uint64(slot)), data)
+	p.metaSlot, p.metaSeq = slot, seq
+	return nil
+}
@@ -0,0 +1,263 @@
+package pager
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+
+	"github.com/toastsandwich/create-database/consts"
+	bnode "github.com/toastsandwich/create-database/internal/BNode"
+	btree "github.com/toastsandwich/create-database/internal/BTree"
+	"github.com/toastsandwich/create-database/utils"
+)
+
+/*
+Pager is the thing that turns a BTree from a pure in-memory data
+structure into one backed by a real file: it mmaps the file, hands out
+BNode views into the mapping via Get, and satisfies New/Del by
+allocating from (or returning to) a free list that lives inside the
+file itself, so freed pages survive a restart instead of leaking.
+
+Layout on disk:
+
+	page 0,1: meta pages - signature, root, free-list head, page count
+	page 2..N: BTree pages and free-list nodes, interleaved as needed
+
+Pages 0 and 1 (see meta.go for why there are two of them) and the
+free list's own node pages are the only pages Pager ever rewrites in
+place; every other page is append-only until it is freed and handed
+back out by the free list, which keeps the mmap'd region simple to
+grow (we only ever extend it, never shrink it mid-run).
+*/
+type Pager struct {
+	Tree btree.BTree
+
+	fp *os.File
+
+	mmap struct {
+		total  int      // mmap size, can be larger than the file
+		chunks [][]byte // multiple mmaps, can be non-continuous
+	}
+
+	page struct {
+		flushed uint64            // number of pages on disk
+		temp    map[uint64][]byte // newly allocated pages, not yet flushed
+	}
+
+	free freeList
+
+	metaSlot int    // which of the two meta pages (0/1) is currently active
+	metaSeq  uint64 // monotonically increasing, breaks ties on restart
+}
+
+// Open mmaps the file at path, adopting its last committed meta page
+// if it has one or starting fresh if not, and returns a Pager whose
+// Tree is wired up to read and write pages through it.
+func Open(path string) (*Pager, error) {
+	fp, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("pager: OpenFile: %w", err)
+	}
+
+	p := &Pager{fp: fp}
+	p.page.temp = map[uint64][]byte{}
+	p.free = freeList{get: p.freeGet, new: p.pageAppend, use: p.pageWriteExisting}
+
+	if err := p.mmapInit(); err != nil {
+		fp.Close()
+		return nil, err
+	}
+
+	p.Tree = btree.BTree{PageGet: p.Get, New: p.New, Del: p.Del, CommitHook: p.commit}
+	p.loadMeta()
+	return p, nil
+}
+
+// Close unmaps the file. Any transaction must already be committed or
+// rolled back - Close does not flush anything on its own.
+func (p *Pager) Close() error {
+	for _, chunk := range p.mmap.chunks {
+		if err := syscall.Munmap(chunk); err != nil {
+			return fmt.Errorf("pager: Munmap: %w", err)
+		}
+	}
+	return p.fp.Close()
+}
+
+// mmapInit maps the whole current file, rounded up to the meta-page
+// pair doubled as often as it takes to cover it, and extends the file
+// to that size; extendMmap grows the mapping in chunks from there as
+// the pager appends pages.
+func (p *Pager) mmapInit() error {
+	fi, err := p.fp.Stat()
+	if err != nil {
+		return fmt.Errorf("pager: Stat: %w", err)
+	}
+	if fi.Size()%consts.BTREE_PAGE_SIZE != 0 {
+		return fmt.Errorf("pager: file size is not a multiple of the page size")
+	}
+
+	size := consts.BTREE_PAGE_SIZE * metaPageCount
+	for size < int(fi.Size()) {
+		size *= 2
+	}
+
+	// the mapping must never cover bytes the file doesn't actually
+	// have - on a brand-new (size 0) file that's the whole mapping, and
+	// touching it faults the process with SIGBUS before Open even
+	// returns.
+	if err := p.fp.Truncate(int64(size)); err != nil {
+		return fmt.Errorf("pager: Truncate: %w", err)
+	}
+
+	chunk, err := syscall.Mmap(int(p.fp.Fd()), 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return fmt.Errorf("pager: Mmap: %w", err)
+	}
+
+	p.mmap.total = size
+	p.mmap.chunks = [][]byte{chunk}
+	p.page.flushed = uint64(fi.Size() / consts.BTREE_PAGE_SIZE)
+	if p.page.flushed < metaPageCount {
+		// brand new file: reserve the meta-page pair
+		p.page.flushed = metaPageCount
+	}
+	return nil
+}
+
+// extendMmap grows the mapping so pages up to npages-1 are addressable.
+func (p *Pager) extendMmap(npages int) error {
+	if npages*consts.BTREE_PAGE_SIZE <= p.mmap.total {
+		return nil
+	}
+	// same reasoning as mmapInit: the new chunk must be backed by real
+	// file bytes before it's mapped, or touching it SIGBUSes.
+	if err := p.fp.Truncate(int64(p.mmap.total + p.mmap.total)); err != nil {
+		return fmt.Errorf("pager: Truncate: %w", err)
+	}
+	chunk, err := syscall.Mmap(int(p.fp.Fd()), int64(p.mmap.total), p.mmap.total,
+		syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return fmt.Errorf("pager: Mmap: %w", err)
+	}
+	p.mmap.total += p.mmap.total
+	p.mmap.chunks = append(p.mmap.chunks, chunk)
+	return nil
+}
+
+// pageGetMapped returns a view into the mmap for a page that is
+// already flushed to disk.
+func (p *Pager) pageGetMapped(ptr uint64) []byte {
+	start := uint64(0)
+	for _, chunk := range p.mmap.chunks {
+		end := start + uint64(len(chunk))/consts.BTREE_PAGE_SIZE
+		if ptr < end {
+			offset := consts.BTREE_PAGE_SIZE * (ptr - start)
+			return chunk[offset : offset+consts.BTREE_PAGE_SIZE]
+		}
+		start = end
+	}
+	panic("pager: page out of bounds")
+}
+
+// Get dereferences a page pointer, whether it has been flushed to disk
+// already or is still sitting in the in-memory temp set.
+func (p *Pager) Get(ptr uint64) bnode.BNode {
+	if data, ok := p.page.temp[ptr]; ok {
+		return bnode.BNode{Data: data}
+	}
+	return bnode.BNode{Data: p.pageGetMapped(ptr)}
+}
+
+// freeGet is Get for the free list: a free-list node can be pushed to
+// or popped from more than once within the same transaction before
+// it's ever flushed, so reads through it have to see staged temp pages
+// too, not just what pageGetMapped finds in the live mmap. A flushed
+// node is returned as a copy, so the free list's edits to it are only
+// staged (via pageWriteExisting) and never land in the live mmap
+// before commit.
+func (p *Pager) freeGet(ptr uint64) []byte {
+	if data, ok := p.page.temp[ptr]; ok {
+		return data
+	}
+	return append([]byte(nil), p.pageGetMapped(ptr)...)
+}
+
+// New allocates a page for node, reusing one from the free list before
+// falling back to extending the file.
+func (p *Pager) New(node bnode.BNode) uint64 {
+	utils.Assert(len(node.Data) <= consts.BTREE_PAGE_SIZE)
+	ptr := p.free.Pop()
+	if ptr == 0 {
+		ptr = p.pageAppend(node.Data)
+		return ptr
+	}
+	p.page.temp[ptr] = node.Data
+	return ptr
+}
+
+// Del frees a page so a later New/Pop can reuse it.
+func (p *Pager) Del(ptr uint64) {
+	p.free.Push(ptr)
+}
+
+// pageAppend stages data as a brand new page past the end of what has
+// been flushed, growing the mmap if needed; it is also used by the
+// free list to allocate its own node pages.
+func (p *Pager) pageAppend(data []byte) uint64 {
+	// temp also holds reused pages below flushed, so count past flushed
+	// only the pages appended since the last commit
+	ptr := p.page.flushed
+	for {
+		if _, staged := p.page.temp[ptr]; !staged {
+			break
+		}
+		ptr++
+	}
+	p.page.temp[ptr] = data
+	utils.Assert(p.extendMmap(int(ptr)+1) == nil)
+	return ptr
+}
+
+// pageWriteExisting overwrites an already-allocated page in place
+// (used by the free list when it mutates one of its own nodes).
+func (p *Pager) pageWriteExisting(ptr uint64, data []byte) {
+	p.page.temp[ptr] = data
+}
+
+// commit is wired to BTree.CommitHook: it durably writes every page
+// staged since the last commit, fsyncs so they can never be lost, and
+// only then swaps the meta page to point at root - see meta.go for why
+// that ordering is what makes a crash mid-commit safe.
+func (p *Pager) commit(root uint64) error {
+	flushedAtStart := p.page.flushed
+	var appended uint64
+	for ptr, data := range p.page.temp {
+		if ptr >= flushedAtStart {
+			if err := p.writePage(ptr, data); err != nil {
+				return err
+			}
+			appended++
+		} else {
+			copy(p.pageGetMapped(ptr), data)
+		}
+	}
+	// only pages actually appended past the old end of file grow the
+	// page count - temp also holds free-list pages reused below
+	// flushedAtStart, which must not double-count as new pages (that
+	// would permanently burn page numbers the free list still thinks
+	// are free).
+	p.page.flushed += appended
+	for ptr := range p.page.temp {
+		delete(p.page.temp, ptr)
+	}
+	if err := p.fp.Sync(); err != nil {
+		return err
+	}
+	return p.saveMeta(root)
+}
+
+func (p *Pager) writePage(ptr uint64, data []byte) error {
+	_, err := p.fp.WriteAt(data, int64(ptr)*consts.BTREE_PAGE_SIZE)
+	return err
+}
@@ -0,0 +1,169 @@
+package pager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	bnode "github.com/toastsandwich/create-database/internal/BNode"
+)
+
+// openFreshPager opens path, which must not already exist, and
+// registers it for removal once the test ends.
+func openFreshPager(t *testing.T, path string) *Pager {
+	t.Helper()
+	p, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open(%q): %v", path, err)
+	}
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+// TestOpenFreshFileDoesNotFault is the regression test for the SIGBUS
+// bug in mmapInit: opening a pager on a brand-new, empty file must not
+// map bytes the file doesn't have.
+func TestOpenFreshFileDoesNotFault(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fresh.db")
+	p := openFreshPager(t, path)
+	if p.Tree.Root != 0 {
+		t.Fatalf("a fresh database should start with an empty tree, got root %d", p.Tree.Root)
+	}
+}
+
+// TestCommittedTransactionSurvivesRestart is the regression test for
+// the free-list durability bug: a committed transaction's writes - and
+// the pages it freed - must still be there after Close and a fresh
+// Open, not just while the original Pager is alive.
+func TestCommittedTransactionSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "restart.db")
+
+	p := openFreshPager(t, path)
+	p.Tree.Begin()
+	for i := 0; i < 100; i++ {
+		k := []byte(fmt.Sprintf("key-%05d", i))
+		v := []byte(fmt.Sprintf("val-%05d", i))
+		if err := p.Tree.Insert(k, v); err != nil {
+			t.Fatalf("Insert: %v", err)
+		}
+	}
+	if err := p.Tree.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	p.Tree.Begin()
+	for i := 0; i < 100; i += 2 { // delete every other key, so both inserts and deletes are covered
+		k := []byte(fmt.Sprintf("key-%05d", i))
+		if !p.Tree.Delete(k) {
+			t.Fatalf("key-%05d not found to delete", i)
+		}
+	}
+	if err := p.Tree.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	freeHeadBeforeClose := p.free.head
+	if freeHeadBeforeClose == 0 {
+		t.Fatal("expected a non-empty free list after deleting half the keys")
+	}
+	if err := p.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	p2, err := Open(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer p2.Close()
+
+	for i := 0; i < 100; i++ {
+		k := []byte(fmt.Sprintf("key-%05d", i))
+		v, ok := p2.Tree.Get(k)
+		if i%2 == 0 {
+			if ok {
+				t.Fatalf("key-%05d should have been deleted before the restart", i)
+			}
+			continue
+		}
+		want := fmt.Sprintf("val-%05d", i)
+		if !ok || string(v) != want {
+			t.Fatalf("key-%05d = %q, %v; want %q, true", i, v, ok, want)
+		}
+	}
+
+	if p2.free.head != freeHeadBeforeClose {
+		t.Fatalf("free-list head after restart = %d, want %d (frees were lost)", p2.free.head, freeHeadBeforeClose)
+	}
+}
+
+// TestReopenReadsNothingOnEmptyDB guards against loadMeta mistaking a
+// freshly Truncate'd file for one with a committed meta page.
+func TestReopenReadsNothingOnEmptyDB(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "empty.db")
+	p := openFreshPager(t, path)
+	if err := p.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	p2, err := Open(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer p2.Close()
+	if p2.Tree.Root != 0 {
+		t.Fatalf("reopening a never-committed database should still have an empty tree, got root %d", p2.Tree.Root)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+}
+
+// TestFreeListPushPopWithinOneTransaction is the regression test for
+// the free list's get callback reading straight from the live mmap
+// instead of through Get: freeing several pages in a row, before any
+// of them (or the free-list node they land on) has ever been flushed,
+// must make every single one of them poppable afterward. Before the
+// fix, the second and later Push calls read the free-list node's
+// still-unflushed bytes as zeroed mmap content instead of the
+// just-staged temp copy, which silently dropped earlier entries and
+// eventually handed the free-list node's own page back out as if it
+// were one of the freed pages.
+func TestFreeListPushPopWithinOneTransaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "freelist.db")
+	p := openFreshPager(t, path)
+
+	// four brand-new pages (2, 3, 4, 5 on a fresh file), none of them
+	// flushed yet.
+	var freed []uint64
+	for i := 0; i < 4; i++ {
+		ptr := p.New(bnode.BNode{Data: make([]byte, 4096)})
+		freed = append(freed, ptr)
+	}
+
+	// free all four in a row, against a free list that starts empty -
+	// the first Push allocates the free-list node (also unflushed),
+	// and every Push after it has to read that node back out to append
+	// to it.
+	for _, ptr := range freed {
+		p.free.Push(ptr)
+	}
+
+	want := map[uint64]bool{}
+	for _, ptr := range freed {
+		want[ptr] = true
+	}
+	got := map[uint64]bool{}
+	for i := 0; i < len(freed); i++ {
+		ptr := p.free.Pop()
+		if !want[ptr] {
+			t.Fatalf("Pop() #%d = %d, want one of %v (not yet popped)", i, ptr, freed)
+		}
+		if got[ptr] {
+			t.Fatalf("Pop() returned page %d twice", ptr)
+		}
+		got[ptr] = true
+	}
+	if len(got) != len(freed) {
+		t.Fatalf("popped %d distinct pages, want %d - some were leaked", len(got), len(freed))
+	}
+}
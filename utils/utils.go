@@ -0,0 +1,11 @@
+package utils
+
+// Assert panics if cond is false. It is used throughout internal/BNode,
+// internal/BTree and internal/pager to enforce invariants (bounds,
+// sizes, transaction state) that should never be violated by correct
+// callers - tripping one means a bug upstream, not a recoverable error.
+func Assert(cond bool) {
+	if !cond {
+		panic("assertion failed")
+	}
+}